@@ -0,0 +1,106 @@
+// Package bootstrap implements peer discovery via embedded DNS seeds,
+// following the pattern used by bitcoind and other altcoin full nodes: a
+// handful of operator-run hostnames that resolve to a rotating set of
+// currently-reachable peer addresses.
+package bootstrap
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Lldenaurois/lnd/lnwire"
+)
+
+// DefaultPort is the port assumed for a resolved seed address when the
+// seed's advertised lnwire.DNSSeedAddr carries no port of its own.
+const DefaultPort = 9735
+
+// Seed pairs a gossiped lnwire.DNSSeedAddr with the local-only bootstrap
+// options that aren't part of the wire format.
+type Seed struct {
+	// Addr is the DNS seed descriptor, as decoded off the wire (or
+	// configured locally) by the lnwire package.
+	Addr *lnwire.DNSSeedAddr
+
+	// SRVFilter, if set, is prepended as a subdomain to Addr.Hostname
+	// before the lookup, mirroring the btcd seeder's
+	// "x{filter}.seed.host" convention for requesting a
+	// service-bit-filtered result set. Left empty, Bootstrap performs a
+	// plain A/AAAA lookup against Addr.Hostname.
+	SRVFilter string
+}
+
+// resolveHost mirrors the signature of net.LookupHost, so a Bootstrapper
+// built by New talks to the real resolver while tests can substitute a
+// fake one.
+type resolveHost func(host string) (addrs []string, err error)
+
+// ConnectFunc is supplied by the peer connection manager; Bootstrap invokes
+// it once per resolved address discovered from the seed list.
+type ConnectFunc func(addr *net.TCPAddr)
+
+// Bootstrapper resolves a fixed list of lnwire.DNSSeedAddr seeds into
+// connectable peer addresses, and hands each one to a ConnectFunc supplied
+// by the caller.
+type Bootstrapper struct {
+	seeds   []Seed
+	resolve resolveHost
+}
+
+// New returns a Bootstrapper that queries the given seeds using the
+// standard library resolver.
+func New(seeds []Seed) *Bootstrapper {
+	return &Bootstrapper{
+		seeds:   seeds,
+		resolve: net.LookupHost,
+	}
+}
+
+// Bootstrap queries every configured seed and hands each resolved address
+// to connect. A seed that fails to resolve is skipped rather than treated
+// as fatal, since the remaining seeds may still turn up usable peers.
+func (b *Bootstrapper) Bootstrap(connect ConnectFunc) error {
+	var (
+		lastErr error
+		found   int
+	)
+
+	for _, seed := range b.seeds {
+		host := seed.Addr.Hostname
+		if seed.SRVFilter != "" {
+			host = seed.SRVFilter + "." + host
+		}
+
+		ips, err := b.resolve(host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		port := seed.Addr.Port
+		if port == 0 {
+			port = DefaultPort
+		}
+
+		for _, ip := range ips {
+			addr := net.ParseIP(ip)
+			if addr == nil {
+				continue
+			}
+
+			connect(&net.TCPAddr{IP: addr, Port: port})
+			found++
+		}
+	}
+
+	if found == 0 {
+		if lastErr != nil {
+			return fmt.Errorf("no usable peers returned by any "+
+				"configured dns seed, last error: %v", lastErr)
+		}
+		return fmt.Errorf("no usable peers returned by any " +
+			"configured dns seed")
+	}
+
+	return nil
+}
@@ -0,0 +1,482 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestOnionAddrRoundTripV2 checks that a v2 (short-form) onion address
+// survives a write/read round trip through writeElement/readElement intact.
+func TestOnionAddrRoundTripV2(t *testing.T) {
+	addr := &OnionAddr{
+		Service: bytes.Repeat([]byte{0xaa}, onionV2ServiceLen),
+		Version: 2,
+		Port:    9735,
+	}
+
+	var buf bytes.Buffer
+	if err := writeElement(&buf, []net.Addr{addr}); err != nil {
+		t.Fatalf("failed to write onion addr: %v", err)
+	}
+
+	var addrs []net.Addr
+	if err := readElement(&buf, &addrs); err != nil {
+		t.Fatalf("failed to read onion addr: %v", err)
+	}
+
+	if len(addrs) != 1 {
+		t.Fatalf("expected 1 address, got %d", len(addrs))
+	}
+
+	got, ok := addrs[0].(*OnionAddr)
+	if !ok {
+		t.Fatalf("expected *OnionAddr, got %T", addrs[0])
+	}
+	if got.Version != 2 || got.Port != 9735 ||
+		!bytes.Equal(got.Service, addr.Service) {
+
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got, addr)
+	}
+}
+
+// TestOnionAddrRoundTripV3 is the v3 (long-form) analogue of
+// TestOnionAddrRoundTripV2.
+func TestOnionAddrRoundTripV3(t *testing.T) {
+	addr := &OnionAddr{
+		Service: bytes.Repeat([]byte{0xbb}, onionV3ServiceLen),
+		Version: 3,
+		Port:    9736,
+	}
+
+	var buf bytes.Buffer
+	if err := writeElement(&buf, []net.Addr{addr}); err != nil {
+		t.Fatalf("failed to write onion addr: %v", err)
+	}
+
+	var addrs []net.Addr
+	if err := readElement(&buf, &addrs); err != nil {
+		t.Fatalf("failed to read onion addr: %v", err)
+	}
+
+	if len(addrs) != 1 {
+		t.Fatalf("expected 1 address, got %d", len(addrs))
+	}
+
+	got, ok := addrs[0].(*OnionAddr)
+	if !ok {
+		t.Fatalf("expected *OnionAddr, got %T", addrs[0])
+	}
+	if got.Version != 3 || got.Port != 9736 ||
+		!bytes.Equal(got.Service, addr.Service) {
+
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got, addr)
+	}
+}
+
+// TestReadNetAddrUnknownDescriptor asserts that an unrecognized address
+// descriptor is a hard error rather than silently decoding into a
+// zero-port TCP address.
+func TestReadNetAddrUnknownDescriptor(t *testing.T) {
+	_, err := readNetAddr(bytes.NewReader(nil), addressType(0xff))
+	if err == nil {
+		t.Fatal("expected an error for an unknown address descriptor")
+	}
+}
+
+// TestLengthPrefixMigration checks that OpaqueReason round-trips under both
+// the legacy fixed-width uint16 prefix and the VarInt-gated prefix, and
+// that toggling VarIntLengthPrefixes actually changes the bytes on the
+// wire.
+func TestLengthPrefixMigration(t *testing.T) {
+	payload := OpaqueReason(bytes.Repeat([]byte{0x42}, 300))
+
+	for _, useVarInt := range []bool{false, true} {
+		old := VarIntLengthPrefixes
+		VarIntLengthPrefixes = useVarInt
+		func() {
+			defer func() { VarIntLengthPrefixes = old }()
+
+			var buf bytes.Buffer
+			if err := writeElement(&buf, payload); err != nil {
+				t.Fatalf("failed to write OpaqueReason "+
+					"(VarIntLengthPrefixes=%v): %v",
+					useVarInt, err)
+			}
+
+			// A legacy uint16 prefix is always 2 bytes; a
+			// 300-byte payload needs the 0xfd-discriminated
+			// 3-byte VarInt form.
+			wantPrefixLen := 2
+			if useVarInt {
+				wantPrefixLen = 3
+			}
+			if buf.Len() != wantPrefixLen+len(payload) {
+				t.Fatalf("unexpected encoded length: got %d, "+
+					"want %d", buf.Len(),
+					wantPrefixLen+len(payload))
+			}
+
+			var out OpaqueReason
+			if err := readElement(&buf, &out); err != nil {
+				t.Fatalf("failed to read OpaqueReason "+
+					"(VarIntLengthPrefixes=%v): %v",
+					useVarInt, err)
+			}
+			if !bytes.Equal(out, payload) {
+				t.Fatalf("round trip mismatch: got %x, want %x",
+					out, payload)
+			}
+		}()
+	}
+}
+
+// TestValidateDNSSeedHostname checks that the hostname validator accepts
+// well-formed DNS-label hostnames and rejects ones that are empty,
+// oversized, missing a '.', or that carry a character outside the DNS
+// label charset (including a space, which is printable ASCII but not a
+// DNS label character).
+func TestValidateDNSSeedHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		valid    bool
+	}{
+		{"seed.lightning.example.com", true},
+		{"x1-seed.example.com", true},
+		{"nodotsomehow", false},
+		{"", false},
+		{"has a space.example.com", false},
+		{"has/a/slash.example.com", false},
+		{strings.Repeat("a", maxDNSSeedHostnameLen+1) + ".com", false},
+	}
+
+	for _, test := range tests {
+		err := validateDNSSeedHostname(test.hostname)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid, got error: %v",
+				test.hostname, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %q to be rejected", test.hostname)
+		}
+	}
+}
+
+// TestTLVStreamSetOrdering checks that Set keeps the stream sorted in
+// strictly-ascending type order regardless of insertion order, and that
+// Get finds records by type.
+func TestTLVStreamSetOrdering(t *testing.T) {
+	var stream TLVStream
+	stream.Set(5, []byte("five"))
+	stream.Set(1, []byte("one"))
+	stream.Set(3, []byte("three"))
+
+	wantOrder := []uint64{1, 3, 5}
+	for i, record := range stream {
+		if record.Type != wantOrder[i] {
+			t.Fatalf("record %d: got type %d, want %d", i,
+				record.Type, wantOrder[i])
+		}
+	}
+
+	val, ok := stream.Get(3)
+	if !ok || string(val) != "three" {
+		t.Fatalf("Get(3) = (%q, %v), want (\"three\", true)", val, ok)
+	}
+
+	// Setting an existing type should replace its value in place rather
+	// than appending a duplicate.
+	stream.Set(3, []byte("THREE"))
+	if len(stream) != 3 {
+		t.Fatalf("expected 3 records after replacing an existing "+
+			"type, got %d", len(stream))
+	}
+	val, ok = stream.Get(3)
+	if !ok || string(val) != "THREE" {
+		t.Fatalf("Get(3) after replace = (%q, %v), want "+
+			"(\"THREE\", true)", val, ok)
+	}
+}
+
+// TestTLVStreamRoundTripUnknownOddType checks that a stream containing an
+// odd (ignorable) type this build has no typed accessor for still
+// round-trips through Encode/DecodeTLVStream byte-for-byte.
+func TestTLVStreamRoundTripUnknownOddType(t *testing.T) {
+	stream := TLVStream{
+		{Type: 1, Value: []byte("hello")},
+		{Type: 3, Value: []byte("world")},
+	}
+
+	var buf bytes.Buffer
+	if err := stream.Encode(&buf); err != nil {
+		t.Fatalf("failed to encode stream: %v", err)
+	}
+
+	got, err := DecodeTLVStream(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode stream: %v", err)
+	}
+
+	if len(got) != len(stream) {
+		t.Fatalf("got %d records, want %d", len(got), len(stream))
+	}
+	for i, record := range got {
+		if record.Type != stream[i].Type ||
+			!bytes.Equal(record.Value, stream[i].Value) {
+
+			t.Fatalf("record %d mismatch: got %+v, want %+v",
+				i, record, stream[i])
+		}
+	}
+}
+
+// TestTLVStreamRejectsUnknownEvenType checks that DecodeTLVStream refuses
+// to silently ignore an unrecognized *even* (mandatory) type.
+func TestTLVStreamRejectsUnknownEvenType(t *testing.T) {
+	stream := TLVStream{{Type: 2, Value: []byte("mandatory")}}
+
+	var buf bytes.Buffer
+	if err := stream.Encode(&buf); err != nil {
+		t.Fatalf("failed to encode stream: %v", err)
+	}
+
+	if _, err := DecodeTLVStream(&buf); err == nil {
+		t.Fatal("expected an error decoding an unknown even tlv type")
+	}
+}
+
+// TestTLVStreamRejectsOutOfOrder checks that Encode refuses a stream whose
+// records are not in strictly-ascending type order.
+func TestTLVStreamRejectsOutOfOrder(t *testing.T) {
+	stream := TLVStream{
+		{Type: 3, Value: []byte("three")},
+		{Type: 1, Value: []byte("one")},
+	}
+
+	var buf bytes.Buffer
+	if err := stream.Encode(&buf); err == nil {
+		t.Fatal("expected an error encoding an out-of-order stream")
+	}
+}
+
+// TestTLVStreamRejectsNonMinimalVarInt checks that DecodeTLVStream rejects
+// a stream whose type varint was encoded non-minimally, even though the
+// bytes otherwise parse.
+func TestTLVStreamRejectsNonMinimalVarInt(t *testing.T) {
+	var buf bytes.Buffer
+
+	// A non-canonical 3-byte encoding of the type 1 (which fits in a
+	// single byte) should be rejected by ReadVarInt before the stream
+	// logic ever sees it.
+	buf.WriteByte(0xfd)
+	buf.Write([]byte{0x00, 0x01})
+	if err := WriteVarInt(&buf, 0); err != nil {
+		t.Fatalf("failed to write length: %v", err)
+	}
+
+	if _, err := DecodeTLVStream(&buf); err == nil {
+		t.Fatal("expected an error decoding a non-canonical varint type")
+	}
+}
+
+// channelUpdatePayload exercises the same writeElements/readElements call
+// shape (a handful of fixed-width fields plus a TLV tail) the real
+// ChannelUpdate gossip message will have. ChannelUpdate itself doesn't
+// exist in this tree yet, so this stand-in is what
+// BenchmarkChannelUpdateEncode/Decode benchmark in the meantime; swap it
+// for the genuine type once its struct lands.
+type channelUpdatePayload struct {
+	Timestamp       uint32
+	MessageFlags    uint8
+	ChannelFlags    uint8
+	CLTVExpiryDelta uint16
+	HTLCMinimumMsat uint64
+	FeeBaseMsat     uint32
+	FeeRateMsat     uint32
+	ExtraData       ExtraOpaqueData
+}
+
+func (c *channelUpdatePayload) Encode(w io.Writer) error {
+	return writeElements(w,
+		c.Timestamp,
+		c.MessageFlags,
+		c.ChannelFlags,
+		c.CLTVExpiryDelta,
+		c.HTLCMinimumMsat,
+		c.FeeBaseMsat,
+		c.FeeRateMsat,
+		c.ExtraData,
+	)
+}
+
+func (c *channelUpdatePayload) Decode(r io.Reader) error {
+	if err := readElements(r,
+		&c.Timestamp,
+		&c.MessageFlags,
+		&c.ChannelFlags,
+		&c.CLTVExpiryDelta,
+		&c.HTLCMinimumMsat,
+		&c.FeeBaseMsat,
+		&c.FeeRateMsat,
+	); err != nil {
+		return err
+	}
+
+	extra, err := DecodeTLVStream(r)
+	if err != nil {
+		return err
+	}
+	c.ExtraData = extra
+
+	return nil
+}
+
+// nodeAnnouncementPayload is the NodeAnnouncement analogue of
+// channelUpdatePayload above: a timestamp, an address list, and a TLV
+// tail, which is the shape of the fields NodeAnnouncement.Encode/Decode
+// will exercise once that struct exists in this tree.
+type nodeAnnouncementPayload struct {
+	Timestamp uint32
+	Addresses []net.Addr
+	ExtraData ExtraOpaqueData
+}
+
+func (n *nodeAnnouncementPayload) Encode(w io.Writer) error {
+	return writeElements(w,
+		n.Timestamp,
+		n.Addresses,
+		n.ExtraData,
+	)
+}
+
+func (n *nodeAnnouncementPayload) Decode(r io.Reader) error {
+	if err := readElements(r, &n.Timestamp, &n.Addresses); err != nil {
+		return err
+	}
+
+	extra, err := DecodeTLVStream(r)
+	if err != nil {
+		return err
+	}
+	n.ExtraData = extra
+
+	return nil
+}
+
+// BenchmarkChannelUpdateEncode reports allocations/op for encoding a
+// ChannelUpdate-shaped message through the pooled WriteMessageBuffered
+// façade.
+func BenchmarkChannelUpdateEncode(b *testing.B) {
+	msg := &channelUpdatePayload{
+		Timestamp:       1572480000,
+		MessageFlags:    1,
+		ChannelFlags:    0,
+		CLTVExpiryDelta: 40,
+		HTLCMinimumMsat: 1000,
+		FeeBaseMsat:     1000,
+		FeeRateMsat:     10,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := WriteMessageBuffered(&buf, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkChannelUpdateDecode is the read-side counterpart to
+// BenchmarkChannelUpdateEncode.
+func BenchmarkChannelUpdateDecode(b *testing.B) {
+	msg := &channelUpdatePayload{
+		Timestamp:       1572480000,
+		MessageFlags:    1,
+		ChannelFlags:    0,
+		CLTVExpiryDelta: 40,
+		HTLCMinimumMsat: 1000,
+		FeeBaseMsat:     1000,
+		FeeRateMsat:     10,
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf); err != nil {
+		b.Fatal(err)
+	}
+	payload := buf.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var out channelUpdatePayload
+		r := bytes.NewReader(payload)
+		if err := ReadMessageBuffered(r, uint32(len(payload)), &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNodeAnnouncementEncode reports allocations/op for encoding a
+// NodeAnnouncement-shaped message, including the []net.Addr list that
+// makes this message's encode path allocation-heavy in practice.
+func BenchmarkNodeAnnouncementEncode(b *testing.B) {
+	msg := &nodeAnnouncementPayload{
+		Timestamp: 1572480000,
+		Addresses: []net.Addr{
+			&net.TCPAddr{IP: net.ParseIP("4.2.2.2"), Port: 9735},
+			&OnionAddr{
+				Service: bytes.Repeat([]byte{0xaa}, onionV2ServiceLen),
+				Version: 2,
+				Port:    9735,
+			},
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := WriteMessageBuffered(&buf, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNodeAnnouncementDecode is the read-side counterpart to
+// BenchmarkNodeAnnouncementEncode.
+func BenchmarkNodeAnnouncementDecode(b *testing.B) {
+	msg := &nodeAnnouncementPayload{
+		Timestamp: 1572480000,
+		Addresses: []net.Addr{
+			&net.TCPAddr{IP: net.ParseIP("4.2.2.2"), Port: 9735},
+			&OnionAddr{
+				Service: bytes.Repeat([]byte{0xaa}, onionV2ServiceLen),
+				Version: 2,
+				Port:    9735,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf); err != nil {
+		b.Fatal(err)
+	}
+	payload := buf.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var out nodeAnnouncementPayload
+		r := bytes.NewReader(payload)
+		if err := ReadMessageBuffered(r, uint32(len(payload)), &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}